@@ -3,10 +3,13 @@ package etcd_leader_election
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
 	_ "go.etcd.io/etcd/tests/v3/framework/integration"
@@ -21,18 +24,294 @@ type Config struct {
 	ElectionPrefix	 string
 	EtcdEndpoint string
 	InstanceId string
+	// The value published under the election key once this caller becomes the leader, visible to
+	// observers through CurrentLeader/Observe/LeaderChangedCh. Defaults to InstanceId when empty.
+	// Useful for service-discovery style use cases where followers need more than just an id, e.g.
+	// "host:port,version=3". Call LeaderElection.Proclaim to update it later without resigning.
+	InitialValue string
 	// This is used for unit tests only. Don't need to set it for production.
 	EtcdClient *clientv3.Client
+
+	// When true, a supervising goroutine keeps the caller in the race after its etcd session is lost
+	// instead of surfacing the loss on AnyErrorCh and giving up: it opens a new session and, if the
+	// previous leader key is still present on the server, resumes leadership on it via
+	// concurrency.ResumeElection instead of losing a tick to a fresh Campaign. State transitions are
+	// reported on StateCh.
+	AutoResume bool
+	// How long to wait between reconnect attempts while AutoResume is enabled and etcd is unreachable.
+	// Defaults to EtcdSessionTTL when zero.
+	ResumeBackoff time.Duration
+
+	// How often the health-check loop issues a lightweight Get against ElectionPrefix to detect a
+	// silently stalled watch (see WatchUnhealthyTimeout). Defaults to 10s when zero.
+	WatchHealthCheckInterval time.Duration
+	// If the health-check loop can't get a successful Get response against ElectionPrefix for this long,
+	// the watch the campaign goroutine is relying on (Campaign's internal watch, or the wait on
+	// session.Done()) is considered stalled: it's cancelled, the stall is logged, and ErrWatchUnhealthy is
+	// pushed onto AnyErrorCh (unless AutoResume is set). Defaults to 60s when zero.
+	WatchUnhealthyTimeout time.Duration
+
+	// When set, campaigns started/won, session expirations, watch-unhealthy events, leadership duration,
+	// and a current-role gauge are registered against it, labeled by ElectionPrefix and InstanceId. Nil
+	// (the default) disables metrics entirely.
+	Metrics prometheus.Registerer
+	// Optional hook for structured logging, tracing, or any other instrumentation that doesn't go through
+	// Prometheus. Nil (the default) is equivalent to NoopObserver{}.
+	Observer Observer
+}
+
+// Observer lets callers hook into a LeaderElection's lifecycle without depending on Prometheus. Embed
+// NoopObserver to implement only the callbacks you care about.
+type Observer interface {
+	// OnCampaignStart is called every time this instance attempts to (re)enter the race for the leadership,
+	// whether via a fresh Campaign or, after a reconnect, an attempt to resume a still-present leader key.
+	OnCampaignStart()
+	// OnBecomeLeader is called every time this instance wins the leadership, through a fresh Campaign or
+	// a resume after a reconnect.
+	OnBecomeLeader()
+	// OnResign is called when Close() runs on an instance that has won the leadership at least once, even
+	// if that leadership was already lost before Close(). Not called if this instance never won the
+	// leadership in the first place.
+	OnResign()
+	// OnError is called for every error pushed onto AnyErrorCh, including ErrWatchUnhealthy.
+	OnError(err error)
+}
+
+// NoopObserver is an Observer whose methods all do nothing; embed it to implement Observer partially.
+type NoopObserver struct{}
+
+func (NoopObserver) OnCampaignStart() {}
+func (NoopObserver) OnBecomeLeader()  {}
+func (NoopObserver) OnResign()        {}
+func (NoopObserver) OnError(error)    {}
+
+// electionMetrics bundles the Prometheus collectors for a single LeaderElection. A nil *electionMetrics is
+// valid and every method on it is a no-op, so call sites don't need to check Config.Metrics themselves.
+type electionMetrics struct {
+	campaignsStarted prometheus.Counter
+	campaignsWon prometheus.Counter
+	sessionExpirations prometheus.Counter
+	watchUnhealthyEvents prometheus.Counter
+	leadershipDuration prometheus.Histogram
+	isLeader prometheus.Gauge
+}
+
+func newElectionMetrics(reg prometheus.Registerer, electionPrefix, instanceId string) *electionMetrics {
+	if reg == nil {
+		return nil
+	}
+	constLabels := prometheus.Labels{"election_prefix": electionPrefix, "instance_id": instanceId}
+	m := &electionMetrics{}
+	m.campaignsStarted = registerOrReuse(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd_leader_election",
+		Name: "campaigns_started_total",
+		Help: "Number of times this instance entered the race for the leadership, via a fresh Campaign or an attempt to resume a previous leader key.",
+		ConstLabels: constLabels,
+	})).(prometheus.Counter)
+	m.campaignsWon = registerOrReuse(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd_leader_election",
+		Name: "campaigns_won_total",
+		Help: "Number of times this instance won the leadership, via a fresh Campaign or a resume.",
+		ConstLabels: constLabels,
+	})).(prometheus.Counter)
+	m.sessionExpirations = registerOrReuse(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd_leader_election",
+		Name: "session_expirations_total",
+		Help: "Number of times this instance's etcd session was lost.",
+		ConstLabels: constLabels,
+	})).(prometheus.Counter)
+	m.watchUnhealthyEvents = registerOrReuse(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "etcd_leader_election",
+		Name: "watch_unhealthy_total",
+		Help: "Number of times the health-check loop found the watch stalled.",
+		ConstLabels: constLabels,
+	})).(prometheus.Counter)
+	m.leadershipDuration = registerOrReuse(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "etcd_leader_election",
+		Name: "leadership_duration_seconds",
+		Help: "How long each stint as leader lasted, from BecomeLeaderCh to losing the session.",
+		ConstLabels: constLabels,
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	})).(prometheus.Histogram)
+	m.isLeader = registerOrReuse(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "etcd_leader_election",
+		Name: "is_leader",
+		Help: "1 if this instance currently holds the leadership, 0 otherwise.",
+		ConstLabels: constLabels,
+	})).(prometheus.Gauge)
+	return m
+}
+
+// registerOrReuse registers c against reg, same as MustRegister, except that a collector already registered
+// under the same name and labels (e.g. from a prior LeaderElection for the same ElectionPrefix/InstanceId,
+// restarted after an AnyErrorCh error) is reused instead of panicking. Any other registration error -
+// typically a genuine inconsistency between collectors - still panics.
+func registerOrReuse(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+func (m *electionMetrics) incCampaignsStarted() {
+	if m != nil {
+		m.campaignsStarted.Inc()
+	}
+}
+
+func (m *electionMetrics) incCampaignsWon() {
+	if m != nil {
+		m.campaignsWon.Inc()
+	}
+}
+
+func (m *electionMetrics) incSessionExpirations() {
+	if m != nil {
+		m.sessionExpirations.Inc()
+	}
+}
+
+func (m *electionMetrics) incWatchUnhealthyEvents() {
+	if m != nil {
+		m.watchUnhealthyEvents.Inc()
+	}
+}
+
+func (m *electionMetrics) observeLeadershipDuration(d time.Duration) {
+	if m != nil {
+		m.leadershipDuration.Observe(d.Seconds())
+	}
+}
+
+func (m *electionMetrics) setIsLeader(isLeader bool) {
+	if m == nil {
+		return
+	}
+	if isLeader {
+		m.isLeader.Set(1)
+	} else {
+		m.isLeader.Set(0)
+	}
+}
+
+// ErrWatchUnhealthy is pushed onto LeaderElection.AnyErrorCh when the health-check loop hasn't observed a
+// successful Get against ElectionPrefix for Config.WatchUnhealthyTimeout, suggesting the underlying watch
+// is silently stalled even though the etcd session's keepalives are still succeeding (e.g. a one-directional
+// network partition). The caller should treat it like any other AnyErrorCh error: close and, if desired,
+// restart the LeaderElection. With Config.AutoResume set, it is passed to Observer.OnError but not pushed
+// onto AnyErrorCh.
+type ErrWatchUnhealthy struct {
+	ElectionPrefix string
+	Since time.Duration
+}
+
+func (e *ErrWatchUnhealthy) Error() string {
+	return fmt.Sprintf("watch on election prefix %q looks stalled: no successful Get for %s", e.ElectionPrefix, e.Since)
+}
+
+// ElectionState is a lifecycle event reported on LeaderElection.StateCh when Config.AutoResume is set.
+type ElectionState int
+
+const (
+	// StateFollower: campaigning, not (yet, or not anymore) the leader.
+	StateFollower ElectionState = iota
+	// StateLeader: won a fresh Campaign.
+	StateLeader
+	// StateReconnecting: the etcd session was lost and a new one is being established.
+	StateReconnecting
+	// StateResumed: leadership was reclaimed on the previous leader key after a reconnect, rather than
+	// won through a fresh Campaign.
+	StateResumed
+)
+
+func (s ElectionState) String() string {
+	switch s {
+	case StateFollower:
+		return "Follower"
+	case StateLeader:
+		return "Leader"
+	case StateReconnecting:
+		return "Reconnecting"
+	case StateResumed:
+		return "Resumed"
+	default:
+		return "Unknown"
+	}
+}
+
+// sessionAndElection holds the etcd session/election pair backing a LeaderElection, plus a "changed"
+// channel that is closed every time setIfNotClosed() installs a new pair. It is needed because, with
+// Config.AutoResume, the supervising goroutine replaces the session/election after a session loss while
+// other goroutines and methods (Close, Observe, Proclaim, CurrentLeader, observeLeaderChanges) may be
+// reading them concurrently. It also arbitrates the race between a concurrent Close() and a reconnect that
+// just won a new session/election but hasn't published it yet: whichever of closeAndGet()/setIfNotClosed()
+// runs first under mu wins, so the loser never installs a session/election that the winner already decided
+// is (or isn't) the one to resign and close.
+type sessionAndElection struct {
+	mu sync.Mutex
+	session *concurrency.Session
+	election *concurrency.Election
+	changed chan struct{}
+	closed bool
+}
+
+func newSessionAndElection(session *concurrency.Session, election *concurrency.Election) *sessionAndElection {
+	return &sessionAndElection{
+		session: session,
+		election: election,
+		changed: make(chan struct{}),
+	}
+}
+
+func (s *sessionAndElection) get() (*concurrency.Session, *concurrency.Election, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session, s.election, s.changed
+}
+
+// closeAndGet marks the state closed and returns whatever session/election is current at that moment, for
+// Close() to resign and close. Marking closed here, under the same lock setIfNotClosed takes, guarantees a
+// reconnect that hasn't published its new session/election yet will see closed and back off instead of
+// publishing a session nobody will ever resign or close.
+func (s *sessionAndElection) closeAndGet() (*concurrency.Session, *concurrency.Election) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.session, s.election
+}
+
+// setIfNotClosed installs session/election as the current pair and reports true, unless closeAndGet() has
+// already run, in which case it leaves the state untouched and returns false - the caller lost the race
+// with Close() and must resign/close session/election itself instead of publishing them.
+func (s *sessionAndElection) setIfNotClosed(session *concurrency.Session, election *concurrency.Election) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.session = session
+	s.election = election
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return true
 }
 
 type LeaderElection struct {
 	etcdClient *clientv3.Client
-	etcdSession *concurrency.Session
-	etcdElection *concurrency.Election
+	state *sessionAndElection
 	cancelCampaign context.CancelFunc
 	instanceId string
 	isClosed atomic.Bool
+	// Set once this instance has won the leadership at least once, so Close() only reports a resignation
+	// (via Observer.OnResign) for instances that actually held it.
+	hasBeenLeader atomic.Bool
 	dontCloseEtcdClient bool
+	metrics *electionMetrics
+	observer Observer
 
 	// Once a caller is elected as a leader, it will receive a message on this channel.
 	// The leader won't involuntarily lose the leadership as long as its etcd session is valid.
@@ -44,8 +323,152 @@ type LeaderElection struct {
 	// If an error happens before the caller becomes a leader, the caller will never become a leader.
 	// If an error happens after the caller becomes a leader, the caller is not the leader anymore.
 	// When an error happens, the caller should close the LeaderElection object.
+	// When Config.AutoResume is true, a session loss after winning is handled internally and isn't reported
+	// here (see StateCh instead); it still fires if the first Campaign fails, or if Close() interrupts a
+	// reconnect.
 	// Buffer size = 1
 	AnyErrorCh chan error
+	// Fires whenever the current leader changes, including the very first time a leader is observed.
+	// Useful for followers that want to route requests to the leader without campaigning themselves.
+	// Closes when the election is closed and, unless Config.AutoResume is true, when the etcd session backing
+	// this LeaderElection is lost; with AutoResume it keeps following the leader on the new session instead.
+	// Buffer size = 1
+	LeaderChangedCh chan LeaderInfo
+	// Only populated when Config.AutoResume is true. Reports StateFollower/StateLeader/
+	// StateReconnecting/StateResumed transitions so callers can react to a session loss without polling.
+	// Buffer size = 4.
+	StateCh chan ElectionState
+}
+
+// LeaderInfo describes who currently holds the leadership.
+// InstanceId is the campaign value published by the leader, i.e. Config.InitialValue (or Config.InstanceId when
+// InitialValue wasn't set), possibly updated later through Proclaim.
+// Revision is the etcd revision at which this leadership information was observed, useful for detecting staleness.
+type LeaderInfo struct {
+	InstanceId string
+	Revision int64
+}
+
+func leaderInfoFromResponse(resp clientv3.GetResponse) (LeaderInfo, bool) {
+	if len(resp.Kvs) == 0 {
+		return LeaderInfo{}, false
+	}
+	return LeaderInfo{
+		InstanceId: string(resp.Kvs[0].Value),
+		Revision: resp.Header.Revision,
+	}, true
+}
+
+// CurrentLeader returns the instance id of the current leader, or an error if there isn't one yet.
+// This is a one-shot read; use Observe to be notified of subsequent changes.
+func (l *LeaderElection) CurrentLeader(ctx context.Context) (LeaderInfo, error) {
+	_, election, _ := l.state.get()
+	resp, err := election.Leader(ctx)
+	if err != nil {
+		return LeaderInfo{}, err
+	}
+	info, ok := leaderInfoFromResponse(*resp)
+	if !ok {
+		return LeaderInfo{}, errors.New("no leader is currently elected")
+	}
+	return info, nil
+}
+
+// Observe returns a channel that emits the current leader's LeaderInfo every time leadership changes.
+// It is built directly on top of concurrency.Election.Observe, so it works for followers too: a caller
+// doesn't need to win (or even enter) the campaign to learn who the leader is.
+// The returned channel is closed when ctx is done or the underlying watch is closed by the etcd server.
+func (l *LeaderElection) Observe(ctx context.Context) (<-chan LeaderInfo, error) {
+	if l.isClosed.Load() {
+		return nil, errors.New("the leader election is already closed")
+	}
+	_, election, _ := l.state.get()
+	leaderInfoCh := make(chan LeaderInfo, 1)
+	go func() {
+		defer close(leaderInfoCh)
+		for resp := range election.Observe(ctx) {
+			info, ok := leaderInfoFromResponse(resp)
+			if !ok {
+				continue
+			}
+			select {
+			case leaderInfoCh <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return leaderInfoCh, nil
+}
+
+// observeLeaderChanges feeds LeaderChangedCh for the lifetime of the LeaderElection, so that followers can
+// learn about the leader without calling Observe themselves. When autoResume is set, it re-subscribes on
+// the new session/election installed by runCampaignLoop instead of giving up on the first session loss.
+func observeLeaderChanges(ctx context.Context, state *sessionAndElection, instanceId string, logger *log.Logger, leaderChangedCh chan LeaderInfo, autoResume bool) {
+	defer close(leaderChangedCh)
+	for {
+		session, election, changed := state.get()
+		if !drainObserve(ctx, session, election, instanceId, logger, leaderChangedCh) {
+			return
+		}
+		if !autoResume {
+			return
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drainObserve forwards leader changes to leaderChangedCh until the session is done or ctx is cancelled.
+// It returns false when the caller should stop altogether (ctx cancelled), true when it should wait for a
+// new session/election and resubscribe (AutoResume only).
+func drainObserve(ctx context.Context, session *concurrency.Session, election *concurrency.Election, instanceId string, logger *log.Logger, leaderChangedCh chan LeaderInfo) bool {
+	observeCh := election.Observe(ctx)
+	for {
+		select {
+		case resp, ok := <-observeCh:
+			if !ok {
+				return true
+			}
+			info, ok := leaderInfoFromResponse(resp)
+			if !ok {
+				continue
+			}
+			select {
+			case leaderChangedCh <- info:
+			case <-ctx.Done():
+				return false
+			}
+		case <-session.Done():
+			logger.Printf("%s: the session backing Observe is done.\n", instanceId)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// BlockingWaitForLeadership blocks until this instance either becomes the leader or gives up campaigning
+// because of an error on AnyErrorCh, and reports which one happened. When Config.AutoResume is true it
+// still returns true on winning, but a later session loss isn't reported on AnyErrorCh; see StateCh instead.
+func (l *LeaderElection) BlockingWaitForLeadership() bool {
+	select {
+	case <-l.BecomeLeaderCh:
+		return true
+	case <-l.AnyErrorCh:
+		return false
+	}
+}
+
+// Proclaim updates the value published under the election key without resigning the leadership, so the
+// caller's etcd session (and thus its campaign position) is left untouched. It is an error to call this
+// before the caller has become the leader; see BecomeLeaderCh.
+func (l *LeaderElection) Proclaim(ctx context.Context, value string) error {
+	_, election, _ := l.state.get()
+	return election.Proclaim(ctx, value)
 }
 
 // Will resign the leadership (if the caller is elected) and close the etcd session.
@@ -53,14 +476,22 @@ type LeaderElection struct {
 func (l *LeaderElection) Close(logger *log.Logger) {
 	if l.isClosed.Swap(true) {
 		logger.Println(l.instanceId, ": Already closed.")
-		return 
+		return
 	}
 	logger.Println(l.instanceId, ": Canceling the campaign...")
 	l.cancelCampaign()
+	// closeAndGet (not get) marks l.state closed under its own lock, so a reconnect that's just about to
+	// publish a new session/election via setIfNotClosed is guaranteed to see it and back off instead of
+	// racing this snapshot - see sessionAndElection's doc comment.
+	session, election := l.state.closeAndGet()
 	logger.Println(l.instanceId, ": Resigning the election...")
-	l.etcdElection.Resign(context.Background())
+	if l.hasBeenLeader.Load() {
+		l.observer.OnResign()
+	}
+	election.Resign(context.Background())
+	l.metrics.setIsLeader(false)
 	logger.Println(l.instanceId, ": Closing the etcd session...")
-	l.etcdSession.Close()
+	session.Close()
 	if !l.dontCloseEtcdClient {
 		logger.Println(l.instanceId, ": Closing the etcd client...")
 	    l.etcdClient.Close()
@@ -75,16 +506,299 @@ func createEtcdClient(etcdEndpoint string) (*clientv3.Client, error) {
     		Endpoints:   []string{etcdEndpoint},
     		DialTimeout: timeout,
     		Context:	 ctx,
-	})	
+	})
 	return client, err
 }
 
-func StartLeaderElectionAsync(config Config, logger *log.Logger) (LeaderElection, error){
+func newEtcdSession(client *clientv3.Client, config Config) (*concurrency.Session, error) {
+	return concurrency.NewSession(client, concurrency.WithTTL(
+		int(config.EtcdSessionTTL.Seconds()),
+	))
+}
+
+// clearSessionIfNeeded is a best-effort cleanup for a Campaign error that may not reflect reality: the
+// underlying etcd Put can actually commit even though the RPC reports an error back to the client (e.g. a
+// network flap right after the server applied it). Left alone, that leaves an orphaned key under
+// session.Lease() that nobody can remove until the lease's TTL expires, blocking the whole prefix from
+// electing a leader in the meantime. It ranges the election prefix, finds any keys owned by session's
+// lease, and deletes them in a lease-guarded transaction. It always returns a non-nil error describing
+// what happened; campaignErr is folded into it either way.
+func clearSessionIfNeeded(ctx context.Context, client *clientv3.Client, prefix string, session *concurrency.Session, campaignErr error) error {
+	resp, err := client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("campaign failed (%w); also failed to check for an orphaned key: %v", campaignErr, err)
+	}
+	lease := session.Lease()
+	var orphanKeys []string
+	for _, kv := range resp.Kvs {
+		if clientv3.LeaseID(kv.Lease) == lease {
+			orphanKeys = append(orphanKeys, string(kv.Key))
+		}
+	}
+	if len(orphanKeys) == 0 {
+		return fmt.Errorf("campaign failed (%w); no orphaned key under this session's lease was found", campaignErr)
+	}
+	ops := make([]clientv3.Op, 0, len(orphanKeys))
+	for _, key := range orphanKeys {
+		ops = append(ops, clientv3.OpDelete(key))
+	}
+	txnResp, err := client.Txn(ctx).
+		If(clientv3.Compare(clientv3.LeaseValue(orphanKeys[0]), "=", int64(lease))).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("campaign failed (%w); also failed to delete orphaned key(s) %v: %v", campaignErr, orphanKeys, err)
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("campaign failed (%w); orphaned key(s) %v were already gone by the time cleanup ran", campaignErr, orphanKeys)
+	}
+	return fmt.Errorf("campaign failed (%w); cleaned up orphaned key(s) %v so no leader is left blocking the prefix", campaignErr, orphanKeys)
+}
+
+// tryResumeOrCampaign opens a new session and either resumes leadership on prevLeaderKey (if it's still
+// present on the server, i.e. its lease hasn't expired yet, and Proclaim succeeds in re-leasing it under the
+// new session) or runs a fresh, blocking Campaign. The returned bool reports which path was taken.
+func tryResumeOrCampaign(ctx context.Context, client *clientv3.Client, config Config, initialValue string, prevLeaderKey string, prevLeaderRev int64, metrics *electionMetrics, observer Observer, logger *log.Logger) (*concurrency.Session, *concurrency.Election, bool, error) {
+	session, err := newEtcdSession(client, config)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if prevLeaderKey != "" {
+		getResp, err := client.Get(ctx, prevLeaderKey)
+		if err == nil && len(getResp.Kvs) > 0 {
+			logger.Printf("%s: the previous leader key %q is still present, resuming leadership.\n", config.InstanceId, prevLeaderKey)
+			resumedElection := concurrency.ResumeElection(session, config.ElectionPrefix, prevLeaderKey, prevLeaderRev)
+			// The key is still owned by the old session's lease, which nobody is keeping alive anymore and
+			// will expire on its own original schedule. Proclaim re-puts it under the new session's lease
+			// before we report this as a leadership we actually hold; otherwise the server could delete it
+			// out from under us at any moment while we still believe we're the leader.
+			observer.OnCampaignStart()
+			metrics.incCampaignsStarted()
+			if err := resumedElection.Proclaim(ctx, initialValue); err != nil {
+				logger.Printf("%s: failed to re-lease the resumed leader key %q: %v. Falling back to a fresh Campaign.\n", config.InstanceId, prevLeaderKey, err)
+			} else {
+				return session, resumedElection, true, nil
+			}
+		}
+	}
+	election := concurrency.NewElection(session, config.ElectionPrefix)
+	observer.OnCampaignStart()
+	metrics.incCampaignsStarted()
+	if err := election.Campaign(ctx, initialValue); err != nil {
+		err = clearSessionIfNeeded(ctx, client, config.ElectionPrefix, session, err)
+		session.Close()
+		return nil, nil, false, err
+	}
+	return session, election, false, nil
+}
+
+// reconnectWithBackoff retries tryResumeOrCampaign, waiting config.ResumeBackoff (or EtcdSessionTTL, if
+// unset) between attempts, until it succeeds or ctx is cancelled.
+func reconnectWithBackoff(ctx context.Context, client *clientv3.Client, config Config, initialValue string, prevLeaderKey string, prevLeaderRev int64, metrics *electionMetrics, observer Observer, logger *log.Logger) (*concurrency.Session, *concurrency.Election, bool, error) {
+	backoff := config.ResumeBackoff
+	if backoff <= 0 {
+		backoff = config.EtcdSessionTTL
+	}
+	for {
+		session, election, resumed, err := tryResumeOrCampaign(ctx, client, config, initialValue, prevLeaderKey, prevLeaderRev, metrics, observer, logger)
+		if err == nil {
+			return session, election, resumed, nil
+		}
+		logger.Printf("%s: failed to reconnect: %v. Retrying in %s.\n", config.InstanceId, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, nil, false, ctx.Err()
+		}
+	}
+}
+
+// watchHealthMonitor runs for a single campaign/leadership attempt. It polls the election prefix with a
+// lightweight Get every Config.WatchHealthCheckInterval; if none of those Gets succeeds for
+// Config.WatchUnhealthyTimeout, it considers the attempt's watch stalled and calls onUnhealthy to unblock
+// it. Unless Config.AutoResume is set, it first pushes an ErrWatchUnhealthy onto AnyErrorCh, so that error is
+// reported ahead of the one runCampaignLoop reports once it is unblocked. With AutoResume set it pushes
+// nothing: during leadership, onUnhealthy() closes the session and runCampaignLoop reconnects; during the
+// first Campaign, onUnhealthy() cancels it and runCampaignLoop reports the resulting Campaign error on
+// AnyErrorCh. It returns once onUnhealthy has been called or ctx is done, whichever happens first.
+func (l *LeaderElection) watchHealthMonitor(ctx context.Context, client *clientv3.Client, config Config, onUnhealthy func(), logger *log.Logger) {
+	interval := config.WatchHealthCheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	timeout := config.WatchUnhealthyTimeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastHealthy := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			getCtx, cancelGet := context.WithTimeout(ctx, interval)
+			_, err := client.Get(getCtx, config.ElectionPrefix)
+			cancelGet()
+			if err == nil {
+				lastHealthy = time.Now()
+				continue
+			}
+			stalledFor := time.Since(lastHealthy)
+			if stalledFor < timeout {
+				continue
+			}
+			logger.Printf("%s: watch on election prefix %q looks stalled (no successful Get for %s): %v\n", config.InstanceId, config.ElectionPrefix, stalledFor, err)
+			l.metrics.incWatchUnhealthyEvents()
+			unhealthyErr := &ErrWatchUnhealthy{ElectionPrefix: config.ElectionPrefix, Since: stalledFor}
+			l.observer.OnError(unhealthyErr)
+			if !config.AutoResume {
+				// Pushed before onUnhealthy() unblocks runCampaignLoop, whose own error would otherwise
+				// take AnyErrorCh's single buffer slot first.
+				select {
+				case l.AnyErrorCh <- unhealthyErr:
+				default:
+				}
+			}
+			onUnhealthy()
+			return
+		}
+	}
+}
+
+// runCampaignLoop drives the campaign for the lifetime of the LeaderElection. With Config.AutoResume unset
+// it behaves exactly as before: campaign once, and report any error or session loss on AnyErrorCh. With
+// AutoResume set, a session loss no longer ends the loop; it reconnects and keeps competing instead.
+func (l *LeaderElection) runCampaignLoop(campaignCtx context.Context, client *clientv3.Client, config Config, initialValue string, logger *log.Logger) {
+	pushState := func(s ElectionState) {
+		if l.StateCh == nil {
+			return
+		}
+		select {
+		case l.StateCh <- s:
+		default:
+		}
+	}
+
+	session, election, _ := l.state.get()
+	var leaderKey string
+	var leaderRev int64
+	resumed := false
+
+	for attempt := 0; ; attempt++ {
+		// campaignWatchCtx bounds the health monitor for the campaigning phase of this attempt only; it's
+		// cancelled as soon as Campaign returns, win or lose, so it never races with the next phase's monitor.
+		campaignWatchCtx, cancelCampaignWatch := context.WithCancel(campaignCtx)
+		var err error
+		if attempt == 0 {
+			pushState(StateFollower)
+			logger.Printf("%s: Obtaining leadership with etcd prefix: %s\n", config.InstanceId, config.ElectionPrefix)
+			go l.watchHealthMonitor(campaignWatchCtx, client, config, cancelCampaignWatch, logger)
+			l.observer.OnCampaignStart()
+			l.metrics.incCampaignsStarted()
+			// This will block until the caller becomes the leader, an error occurs, or the context is cancelled.
+			if err = election.Campaign(campaignWatchCtx, initialValue); err != nil {
+				// Use campaignCtx, not campaignWatchCtx: the latter may already be cancelled (e.g. by the
+				// health monitor) by the time Campaign returns, which would make this cleanup attempt fail
+				// immediately instead of actually running. campaignCtx is only cancelled by Close().
+				err = clearSessionIfNeeded(campaignCtx, client, config.ElectionPrefix, session, err)
+			}
+		} else {
+			pushState(StateReconnecting)
+			session, election, resumed, err = reconnectWithBackoff(campaignCtx, client, config, initialValue, leaderKey, leaderRev, l.metrics, l.observer, logger)
+			if err == nil && !l.state.setIfNotClosed(session, election) {
+				// Close() won the race: it already resigned/closed whatever was live and marked the state
+				// closed before we could publish this newly (re)acquired session/election, so nobody else
+				// will ever clean these up. Do it here instead of leaving them to leak past Close().
+				logger.Printf("%s: reconnected after Close() was already called; resigning and closing the new session.\n", config.InstanceId)
+				election.Resign(context.Background())
+				session.Close()
+				return
+			}
+		}
+		cancelCampaignWatch()
+		if err != nil {
+			logger.Printf("%s: Campaign() returned an error: %+v.\n", config.InstanceId, err)
+			l.observer.OnError(err)
+			l.AnyErrorCh <- err
+			return
+		}
+
+		logger.Printf("%s: I am the leader for election prefix: %s\n", config.InstanceId, config.ElectionPrefix)
+		leaderKey, leaderRev = election.Key(), election.Rev()
+		leaderSince := time.Now()
+		l.metrics.incCampaignsWon()
+		l.metrics.setIsLeader(true)
+		l.hasBeenLeader.Store(true)
+		l.observer.OnBecomeLeader()
+		if resumed {
+			pushState(StateResumed)
+		} else {
+			pushState(StateLeader)
+		}
+		// The leader will hold the leadership until it resigns or the session expires. The session will keep alive by the underlying etcd client
+		// automatically sending heartbeats to the etcd server. The session will expire if the etcd server does not receive heartbeats from the client within the session TTL.
+		select {
+		case l.BecomeLeaderCh <- struct{}{}:
+		default:
+		}
+
+		// leaderWatchCtx bounds the health monitor for as long as this attempt holds leadership. If the
+		// watch stalls, onUnhealthy closes the session directly, which is the only way to unblock the
+		// <-session.Done() below (it isn't driven by a context). currentSession is passed in explicitly
+		// rather than letting the closure capture the loop variable "session": the next reconnect attempt
+		// reassigns "session" concurrently with this goroutine's in-flight Get, and closing over the
+		// variable would let a stale monitor tear down a freshly reacquired session.
+		leaderWatchCtx, cancelLeaderWatch := context.WithCancel(campaignCtx)
+		go l.watchHealthMonitor(leaderWatchCtx, client, config, func(currentSession *concurrency.Session) func() {
+			return func() {
+				cancelLeaderWatch()
+				currentSession.Close()
+			}
+		}(session), logger)
+		logger.Printf("%s: Waiting for session done.\n", config.InstanceId)
+		<-session.Done()
+		cancelLeaderWatch()
+		l.metrics.observeLeadershipDuration(time.Since(leaderSince))
+		l.metrics.setIsLeader(false)
+		if l.isClosed.Load() {
+			// Close() resigned and closed the session itself; this isn't an unexpected loss, so it isn't
+			// counted as a session expiration. Without AutoResume the lost leadership is still reported.
+			if !config.AutoResume {
+				err := errors.New("the election was closed. I am not the leader anymore")
+				l.observer.OnError(err)
+				select {
+				case l.AnyErrorCh <- err:
+				default:
+				}
+			}
+			return
+		}
+		l.metrics.incSessionExpirations()
+		logger.Printf("%s: The session is done. I am not the leader anymore for election prefix: %s\n", config.InstanceId, config.ElectionPrefix)
+		if !config.AutoResume {
+			err := errors.New("the session is done. I am not the leader anymore")
+			l.observer.OnError(err)
+			l.AnyErrorCh <- err
+			return
+		}
+		if campaignCtx.Err() != nil {
+			return
+		}
+	}
+}
+
+// StartLeaderElectionAsync returns a *LeaderElection rather than a LeaderElection: the struct embeds
+// sync/atomic fields (isClosed, hasBeenLeader), and copying those by value is a go vet "copylocks"
+// violation.
+func StartLeaderElectionAsync(config Config, logger *log.Logger) (*LeaderElection, error){
 	toClose := make([]closable, 0)
 	defer func() {
 		for i := int(len(toClose)) - 1; i >= 0; i-- {
 			toClose[i].Close()
-		}	
+		}
 	}()
 
 	client := config.EtcdClient
@@ -97,58 +811,60 @@ func StartLeaderElectionAsync(config Config, logger *log.Logger) (LeaderElection
 	}
 	if err != nil {
 		logger.Printf("Failed to created an ETCD client with error: %v\n", err)
-		return LeaderElection{}, err
+		return nil, err
 	}
 	toClose = append(toClose, client)
 	logger.Println("Etcd connection is established successfully.")
 
 	// If this caller exits without calling Resign() or Close(), the session will expire after the TTL
 	// and the leadership will be lost, if this caller was the leader.
-    session, err := concurrency.NewSession(client, concurrency.WithTTL(
-		int(config.EtcdSessionTTL.Seconds()),
-	))
+	session, err := newEtcdSession(client, config)
 	if err != nil {
 		logger.Printf("Failed to created an ETCD session with error: %v\n", err)
-		return LeaderElection{}, err
+		return nil, err
 	}
 	toClose = append(toClose, session)
 	logger.Println("Etcd session is created successfully.")
 
 	election := concurrency.NewElection(session, config.ElectionPrefix)
+	initialValue := config.InitialValue
+	if initialValue == "" {
+		initialValue = config.InstanceId
+	}
 	campaignCtx, cancelCampaign := context.WithCancel(context.Background())
+
 	becomeLeaderCh := make(chan struct{}, 1)
 	anyErrorCh := make(chan error, 1)
-	go func(campaignErrorCh chan error, becomeLeaderCh chan struct{}) {
-		logger.Printf("%s: Obtaining leadership with etcd prefix: %s\n", config.InstanceId, config.ElectionPrefix)
-		// This will block until the caller becomes the leader, an error occurs, or the context is cancelled.
-		err := election.Campaign(campaignCtx, config.ElectionPrefix)
-		if err == nil {
-			logger.Printf("%s: I am the leader for election prefix: %s\n", config.InstanceId, config.ElectionPrefix)
-			// The leader will hold the leadership until it resigns or the session expires. The session will keep alive by the underlying etcd client
-			// automatically sending heartbeats to the etcd server. The session will expire if the etcd server does not receive heartbeats from the client within the session TTL.
-			becomeLeaderCh <- struct{}{}
-			logger.Printf("%s: Waiting for session done.\n", config.InstanceId)
-			<-session.Done()
-			logger.Printf("%s: The session is done. I am not the leader anymore for election prefix: %s\n", config.InstanceId, config.ElectionPrefix)
-			anyErrorCh <- errors.New("the session is done. I am not the leader anymore")
-		} else {
-			logger.Printf("%s: Campaign() returned an error: %+v.\n", config.InstanceId, err)
-			anyErrorCh <- err
-		}
-	}(anyErrorCh, becomeLeaderCh)
+	leaderChangedCh := make(chan LeaderInfo, 1)
+	var stateCh chan ElectionState
+	if config.AutoResume {
+		stateCh = make(chan ElectionState, 4)
+	}
 
-	toClose = toClose[:0]
-	return LeaderElection{
+	metrics := newElectionMetrics(config.Metrics, config.ElectionPrefix, config.InstanceId)
+	observer := config.Observer
+	if observer == nil {
+		observer = NoopObserver{}
+	}
+
+	l := &LeaderElection{
 		etcdClient: client,
-		etcdSession: session,
-		etcdElection: election,
+		state: newSessionAndElection(session, election),
 		cancelCampaign: cancelCampaign,
 		instanceId: config.InstanceId,
-		isClosed: atomic.Bool{},
 		dontCloseEtcdClient: dontCloseEtcdClient,
+		metrics: metrics,
+		observer: observer,
 
 		BecomeLeaderCh: becomeLeaderCh,
 		AnyErrorCh: anyErrorCh,
-	}, nil
+		LeaderChangedCh: leaderChangedCh,
+		StateCh: stateCh,
+	}
+
+	go l.runCampaignLoop(campaignCtx, client, config, initialValue, logger)
+	go observeLeaderChanges(campaignCtx, l.state, config.InstanceId, logger, leaderChangedCh, config.AutoResume)
+
+	toClose = toClose[:0]
+	return l, nil
 }
- 
@@ -1,18 +1,63 @@
 package etcd_leader_election
 
 import (
+	"context"
+	"errors"
 	"log"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/tests/v3/framework/integration"
 )
 
+// errorAfterFirstCommitKV wraps a clientv3.KV so that the first Txn().Commit() call through it still
+// executes normally against etcd but reports an error to the caller, as if the response had been lost to
+// a network flap right after the server applied it. Every later call passes through untouched. This is
+// used to exercise clearSessionIfNeeded's orphaned-key cleanup without a real network fault.
+type errorAfterFirstCommitKV struct {
+	clientv3.KV
+	triggered atomic.Bool
+}
+
+func (k *errorAfterFirstCommitKV) Txn(ctx context.Context) clientv3.Txn {
+	return &errorAfterFirstCommitTxn{Txn: k.KV.Txn(ctx), kv: k}
+}
+
+type errorAfterFirstCommitTxn struct {
+	clientv3.Txn
+	kv *errorAfterFirstCommitKV
+}
+
+func (t *errorAfterFirstCommitTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *errorAfterFirstCommitTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *errorAfterFirstCommitTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+func (t *errorAfterFirstCommitTxn) Commit() (*clientv3.TxnResponse, error) {
+	resp, err := t.Txn.Commit()
+	if err == nil && !t.kv.triggered.Swap(true) {
+		return resp, errors.New("injected: network flap after commit")
+	}
+	return resp, err
+}
+
 type testCluster struct {
 	t       *testing.T
 	cluster *integration.Cluster
@@ -69,13 +114,13 @@ func TestSingleCampaign(t *testing.T) {
 		break
 	case <-time.After(5 * time.Second):
 		t.Error("should have become leader")
-	case <-le.ErrorCh:
+	case <-le.AnyErrorCh:
 		t.Error("should have become leader")
 	}
 
 	time.Sleep(5 * time.Second)
 	select {
-	case <-le.ErrorCh:
+	case <-le.AnyErrorCh:
 		t.Error("should have kept leadership")
 	case <-time.After(10 * time.Second):
 		break
@@ -83,7 +128,7 @@ func TestSingleCampaign(t *testing.T) {
 	le.Close(log.Default())
 
 	select {
-	case <-le.ErrorCh:
+	case <-le.AnyErrorCh:
 		break
 	case <-time.After(5 * time.Second):
 		t.Error("should have lost leadership")
@@ -112,7 +157,7 @@ func TestLongLivedLeader(t *testing.T) {
 		break
 	case <-time.After(5 * time.Second):
 		t.Error("should have become leader")
-	case <-leader.ErrorCh:
+	case <-leader.AnyErrorCh:
 		t.Error("should have become leader")
 	}
 
@@ -144,7 +189,7 @@ func TestLongLivedLeader(t *testing.T) {
 	wg.Wait()
 	log.Default().Println("Checking that the leader keeps leadership.")
 	select {
-	case <-leader.ErrorCh:
+	case <-leader.AnyErrorCh:
 		t.Error("should have kept leadership")
 	case <-time.After(3 * time.Second):
 		break
@@ -157,7 +202,7 @@ func TestMultipleCampaigns(t *testing.T) {
 		tc.close()
 	}()
 
-	elections := make([]LeaderElection, 0)
+	elections := make([]*LeaderElection, 0)
 	for i := 0; i < 3; i++ {
 		le, err := StartLeaderElectionAsync(
 			Config{
@@ -193,7 +238,7 @@ func TestYieldingLeadership(t *testing.T) {
 		tc.close()
 	}()
 
-	elections := make([]LeaderElection, 0)
+	elections := make([]*LeaderElection, 0)
 	numInstances := 3
 	for i := 0; i < numInstances; i++ {
 		le, err := StartLeaderElectionAsync(
@@ -255,7 +300,7 @@ func TestLeaderDeath(t *testing.T) {
 		break
 	case <-time.After(5 * time.Second):
 		t.Error("should have become leader")
-	case <-leader.ErrorCh:
+	case <-leader.AnyErrorCh:
 		t.Error("should have become leader")
 	}
 
@@ -280,9 +325,10 @@ func TestLeaderDeath(t *testing.T) {
 		}
 		syncCh <- struct{}{}
 	}()
-	leader.etcdSession.Close()
+	session, _, _ := leader.state.get()
+	session.Close()
 	select {
-	case <-leader.ErrorCh:
+	case <-leader.AnyErrorCh:
 		break
 	case <-time.After(5 * time.Second):
 		t.Error("should have lost leadership after session closed")
@@ -311,7 +357,7 @@ func TestConcurrentCampaigns(t *testing.T) {
 			},
 			log.Default(),
 		)
-		electionParticipants <- &le
+		electionParticipants <- le
 		require.NoError(t, err)
 		var wg sync.WaitGroup
 		defer wg.Wait()
@@ -320,7 +366,7 @@ func TestConcurrentCampaigns(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := <-le.ErrorCh
+			err := <-le.AnyErrorCh
 			log.Default().Println("Election error: ", err)
 			close(cancelCh)
 		}()
@@ -331,7 +377,7 @@ func TestConcurrentCampaigns(t *testing.T) {
 			select {
 			case <-le.BecomeLeaderCh:
 				log.Default().Println("became leader: ", instaceId)
-				leaderCh <- &le
+				leaderCh <- le
 			case <-cancelCh:
 			    break
 			}
@@ -353,6 +399,289 @@ func TestConcurrentCampaigns(t *testing.T) {
 	leader2.Close(log.Default())
 }
 
+func TestObserveLeaderSequence(t *testing.T) {
+	tc := newTestCluster(t)
+	defer func () {
+		log.Default().Println("Closing the cluster")
+		tc.close()
+	}()
+
+	numInstances := 3
+	elections := make([]*LeaderElection, 0)
+	for i := 0; i < numInstances; i++ {
+		le, err := StartLeaderElectionAsync(
+			Config{
+				EtcdSessionTTL: 2,
+				ElectionPrefix: "TestObserveLeaderSequence",
+				EtcdClient: tc.etcdClient(),
+				InstanceId: "instance-" + strconv.Itoa(i),
+			},
+			log.Default(),
+		)
+		require.NoError(t, err)
+		elections = append(elections, le)
+	}
+
+	// Every participant, leader and followers alike, should observe at least one leader on LeaderChangedCh
+	// as the leadership is handed off below. Each stops reading once its own election is closed, so the
+	// ones closed later see more of the hand-offs.
+	seenByInstance := make([][]string, numInstances)
+	var wg sync.WaitGroup
+	for i, le := range elections {
+		wg.Add(1)
+		go func(i int, le *LeaderElection) {
+			defer wg.Done()
+			for j := 0; j < numInstances; j++ {
+				select {
+				case info, ok := <-le.LeaderChangedCh:
+					if !ok {
+						return
+					}
+					seenByInstance[i] = append(seenByInstance[i], info.InstanceId)
+				case <-time.After(10 * time.Second):
+					t.Errorf("instance-%d: timed out waiting for leader change %d", i, j)
+					return
+				}
+			}
+		}(i, le)
+	}
+
+	isClosed := make([]bool, len(elections))
+	for range elections {
+		time.Sleep(time.Second * 3)
+		leaders := 0
+		leaderIdx := -1
+		for i, le := range elections {
+			if isClosed[i] {
+				continue
+			}
+			select {
+			case <-le.BecomeLeaderCh:
+				leaders++
+				leaderIdx = i
+			case <-time.After(3 * time.Second):
+			}
+		}
+		assert.Equal(t, 1, leaders)
+		require.NotEqual(t, -1, leaderIdx)
+		elections[leaderIdx].Close(log.Default())
+		isClosed[leaderIdx] = true
+		log.Default().Println("closed leader", leaderIdx)
+	}
+
+	wg.Wait()
+	for i, seen := range seenByInstance {
+		assert.NotEmpty(t, seen, "instance-%d should have observed at least one leader", i)
+	}
+}
+func TestProclaimUpdatesLeaderValue(t *testing.T) {
+	tc := newTestCluster(t)
+	defer func () {
+		log.Default().Println("Closing the cluster")
+		tc.close()
+	}()
+
+	leader, err := StartLeaderElectionAsync(
+		Config{
+			EtcdSessionTTL: 3,
+			ElectionPrefix: "TestProclaimUpdatesLeaderValue",
+			EtcdClient: tc.etcdClient(),
+			InstanceId: "leader",
+			InitialValue: "leader:1.2.3.4:8080",
+		},
+		log.Default(),
+	)
+	require.NoError(t, err)
+	defer leader.Close(log.Default())
+	select {
+	case <-leader.BecomeLeaderCh:
+		break
+	case <-time.After(5 * time.Second):
+		t.Error("should have become leader")
+	case <-leader.AnyErrorCh:
+		t.Error("should have become leader")
+	}
+
+	info, err := leader.CurrentLeader(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "leader:1.2.3.4:8080", info.InstanceId)
+
+	require.NoError(t, leader.Proclaim(context.Background(), "leader:1.2.3.4:9090"))
+
+	info, err = leader.CurrentLeader(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "leader:1.2.3.4:9090", info.InstanceId)
+}
+func TestAutoResumeAfterSessionLoss(t *testing.T) {
+	tc := newTestCluster(t)
+	defer func () {
+		log.Default().Println("Closing the cluster")
+		tc.close()
+	}()
+
+	leader, err := StartLeaderElectionAsync(
+		Config{
+			// Long enough that a brief member restart below doesn't let the old lease expire on its own;
+			// otherwise the previous leader key would already be gone by the time we check for it, and this
+			// would always fall through to a fresh Campaign instead of genuinely exercising ResumeElection.
+			EtcdSessionTTL: 10,
+			ElectionPrefix: "TestAutoResumeAfterSessionLoss",
+			EtcdClient: tc.etcdClient(),
+			InstanceId: "leader",
+			AutoResume: true,
+			ResumeBackoff: 500 * time.Millisecond,
+		},
+		log.Default(),
+	)
+	require.NoError(t, err)
+	defer leader.Close(log.Default())
+
+	select {
+	case s := <-leader.StateCh:
+		assert.Equal(t, StateFollower, s)
+	case <-time.After(5 * time.Second):
+		t.Error("should have reported StateFollower")
+	}
+	select {
+	case <-leader.BecomeLeaderCh:
+		break
+	case <-time.After(5 * time.Second):
+		t.Error("should have become leader")
+	}
+	select {
+	case s := <-leader.StateCh:
+		assert.Equal(t, StateLeader, s)
+	case <-time.After(5 * time.Second):
+		t.Error("should have reported StateLeader")
+	}
+
+	// Simulate a connection blip, as opposed to a real session loss: stopping the member breaks the
+	// session's keepalive stream (closing session.Done() well before the lease's TTL), but unlike
+	// session.Close(), it doesn't revoke the lease or delete the leader key. Restarting the member shortly
+	// after means the key is still there, under the old (now unrenewed) lease, once the reconnect loop gets
+	// around to checking for it - the scenario ResumeElection/Proclaim are actually meant to handle.
+	tc.cluster.Members[0].Stop(t)
+	time.Sleep(2 * time.Second)
+	tc.cluster.Members[0].Restart(t)
+
+	select {
+	case s := <-leader.StateCh:
+		assert.Equal(t, StateReconnecting, s)
+	case <-time.After(5 * time.Second):
+		t.Error("should have reported StateReconnecting")
+	}
+	select {
+	case <-leader.BecomeLeaderCh:
+		break
+	case <-time.After(10 * time.Second):
+		t.Error("should have reacquired leadership within one TTL or so")
+	}
+	select {
+	case s := <-leader.StateCh:
+		assert.Equal(t, StateResumed, s, "expected a genuine ResumeElection, not a fresh Campaign")
+	case <-time.After(5 * time.Second):
+		t.Error("should have reported StateResumed")
+	}
+
+	info, err := leader.CurrentLeader(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "leader", info.InstanceId)
+
+	select {
+	case <-leader.AnyErrorCh:
+		t.Error("AnyErrorCh should not fire when AutoResume is enabled")
+	default:
+	}
+}
+func TestWatchUnhealthyDetection(t *testing.T) {
+	tc := newTestCluster(t)
+	defer func () {
+		log.Default().Println("Closing the cluster")
+		tc.close()
+	}()
+
+	leader, err := StartLeaderElectionAsync(
+		Config{
+			EtcdSessionTTL: 30,
+			ElectionPrefix: "TestWatchUnhealthyDetection",
+			EtcdClient: tc.etcdClient(),
+			InstanceId: "leader",
+			WatchHealthCheckInterval: 500 * time.Millisecond,
+			WatchUnhealthyTimeout: 2 * time.Second,
+		},
+		log.Default(),
+	)
+	require.NoError(t, err)
+	defer leader.Close(log.Default())
+	select {
+	case <-leader.BecomeLeaderCh:
+		break
+	case <-time.After(5 * time.Second):
+		t.Error("should have become leader")
+	}
+
+	// Pausing the only member starves the health-check loop's Gets, which should be flagged as a stalled
+	// watch well before the (much longer) session TTL would expire.
+	tc.cluster.Members[0].Stop(t)
+	defer tc.cluster.Members[0].Restart(t)
+
+	select {
+	case err := <-leader.AnyErrorCh:
+		var unhealthy *ErrWatchUnhealthy
+		assert.ErrorAs(t, err, &unhealthy)
+	case <-time.After(15 * time.Second):
+		t.Error("should have reported a stalled watch")
+	}
+}
+func TestOrphanedKeyCleanupAfterUndeterminedCampaignError(t *testing.T) {
+	tc := newTestCluster(t)
+	defer func () {
+		log.Default().Println("Closing the cluster")
+		tc.close()
+	}()
+
+	faultyClient := tc.etcdClient()
+	faultyClient.KV = &errorAfterFirstCommitKV{KV: faultyClient.KV}
+
+	flaky, err := StartLeaderElectionAsync(
+		Config{
+			EtcdSessionTTL: 30,
+			ElectionPrefix: "TestOrphanedKeyCleanup",
+			EtcdClient: faultyClient,
+			InstanceId: "flaky",
+		},
+		log.Default(),
+	)
+	require.NoError(t, err)
+	defer flaky.Close(log.Default())
+
+	select {
+	case campaignErr := <-flaky.AnyErrorCh:
+		assert.Contains(t, campaignErr.Error(), "cleaned up orphaned key")
+	case <-time.After(5 * time.Second):
+		t.Error("should have reported the injected campaign error")
+	}
+
+	// With the orphaned key cleaned up, a genuine participant should win the election right away, instead
+	// of waiting out the (much longer) session TTL for the orphan's lease to expire.
+	follower, err := StartLeaderElectionAsync(
+		Config{
+			EtcdSessionTTL: 30,
+			ElectionPrefix: "TestOrphanedKeyCleanup",
+			EtcdClient: tc.etcdClient(),
+			InstanceId: "follower",
+		},
+		log.Default(),
+	)
+	require.NoError(t, err)
+	defer follower.Close(log.Default())
+	select {
+	case <-follower.BecomeLeaderCh:
+		break
+	case <-time.After(5 * time.Second):
+		t.Error("should have become leader once the orphaned key was cleaned up")
+	}
+}
 func TestBlockingWait(t *testing.T) {
 	tc := newTestCluster(t)
 	defer func () {
@@ -376,10 +705,10 @@ func TestBlockingWait(t *testing.T) {
 			},
 			log.Default(),
 		)
-		electionParticipants <- &le
+		electionParticipants <- le
 		require.NoError(t, err)
 		if le.BlockingWaitForLeadership() {
-			leaderCh <- &le
+			leaderCh <- le
 		}
 	}
 
@@ -397,3 +726,130 @@ func TestBlockingWait(t *testing.T) {
 	}
 	leader2.Close(log.Default())
 }
+
+// recordingObserver records every callback it receives so tests can assert on the sequence without racing
+// on unexported LeaderElection state.
+type recordingObserver struct {
+	mu    sync.Mutex
+	calls []string
+	errs  []error
+}
+
+func (o *recordingObserver) OnCampaignStart() { o.record("OnCampaignStart") }
+func (o *recordingObserver) OnBecomeLeader()  { o.record("OnBecomeLeader") }
+func (o *recordingObserver) OnResign()        { o.record("OnResign") }
+
+func (o *recordingObserver) OnError(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, "OnError")
+	o.errs = append(o.errs, err)
+}
+
+func (o *recordingObserver) record(call string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, call)
+}
+
+func (o *recordingObserver) sawCall(call string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, c := range o.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetricsAndObserverInstrumentation(t *testing.T) {
+	tc := newTestCluster(t)
+	defer func () {
+		log.Default().Println("Closing the cluster")
+		tc.close()
+	}()
+
+	registry := prometheus.NewRegistry()
+	observer := &recordingObserver{}
+
+	leader, err := StartLeaderElectionAsync(
+		Config{
+			EtcdSessionTTL: 30,
+			ElectionPrefix: "TestMetricsAndObserverInstrumentation",
+			EtcdClient: tc.etcdClient(),
+			InstanceId: "leader",
+			Metrics: registry,
+			Observer: observer,
+		},
+		log.Default(),
+	)
+	require.NoError(t, err)
+	defer leader.Close(log.Default())
+	select {
+	case <-leader.BecomeLeaderCh:
+		break
+	case <-time.After(5 * time.Second):
+		t.Error("should have become leader")
+	}
+
+	assert.True(t, observer.sawCall("OnCampaignStart"))
+	assert.True(t, observer.sawCall("OnBecomeLeader"))
+
+	metricFamilies, err := registry.Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, mf := range metricFamilies {
+		names[mf.GetName()] = true
+	}
+	assert.True(t, names["etcd_leader_election_campaigns_started_total"])
+	assert.True(t, names["etcd_leader_election_campaigns_won_total"])
+	assert.True(t, names["etcd_leader_election_is_leader"])
+
+	leader.Close(log.Default())
+	assert.True(t, observer.sawCall("OnResign"))
+}
+
+// TestMetricsSurviveRestartWithSameRegistry exercises the recovery pattern Config.Metrics is meant to
+// support: closing a LeaderElection after an error and starting a new one with the same registry and the
+// same ElectionPrefix/InstanceId must reuse the existing collectors instead of panicking on
+// AlreadyRegisteredError.
+func TestMetricsSurviveRestartWithSameRegistry(t *testing.T) {
+	tc := newTestCluster(t)
+	defer func () {
+		log.Default().Println("Closing the cluster")
+		tc.close()
+	}()
+
+	registry := prometheus.NewRegistry()
+	config := Config{
+		EtcdSessionTTL: 30,
+		ElectionPrefix: "TestMetricsSurviveRestartWithSameRegistry",
+		EtcdClient: tc.etcdClient(),
+		InstanceId: "leader",
+		Metrics: registry,
+	}
+
+	first, err := StartLeaderElectionAsync(config, log.Default())
+	require.NoError(t, err)
+	select {
+	case <-first.BecomeLeaderCh:
+		break
+	case <-time.After(5 * time.Second):
+		t.Error("should have become leader")
+	}
+	first.Close(log.Default())
+
+	config.EtcdClient = tc.etcdClient()
+	assert.NotPanics(t, func() {
+		second, err := StartLeaderElectionAsync(config, log.Default())
+		require.NoError(t, err)
+		defer second.Close(log.Default())
+		select {
+		case <-second.BecomeLeaderCh:
+			break
+		case <-time.After(5 * time.Second):
+			t.Error("should have become leader")
+		}
+	})
+}